@@ -0,0 +1,136 @@
+package xem
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AniDB title types
+const (
+	TitleMain     = "main"
+	TitleSynonym  = "syn"
+	TitleShort    = "short"
+	TitleOfficial = "official"
+)
+
+// datTitleTypes maps the numeric title types of the DAT dump to their names.
+var datTitleTypes = map[string]string{
+	"1": TitleMain,
+	"2": TitleSynonym,
+	"3": TitleShort,
+	"4": TitleOfficial,
+}
+
+// AnimeTitle is a single title from the AniDB anime-titles dump.
+type AnimeTitle struct {
+	AniDBID  int
+	Type     string
+	Language string
+	Title    string
+}
+
+type animeTitlesXML struct {
+	Anime []struct {
+		AID    int `xml:"aid,attr"`
+		Titles []struct {
+			Type     string `xml:"type,attr"`
+			Language string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+			Title    string `xml:",chardata"`
+		} `xml:"title"`
+	} `xml:"anime"`
+}
+
+// LoadAnimeTitles loads an AniDB anime-titles dump from a local file. Both the
+// XML and DAT formats are supported, optionally gzip compressed, and are
+// detected from the file name.
+func LoadAnimeTitles(path string) ([]AnimeTitle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := path
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress %v: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	if strings.HasSuffix(name, ".xml") {
+		return ParseAnimeTitles(r)
+	}
+	return ParseAnimeTitlesDAT(r)
+}
+
+// ParseAnimeTitles parses the XML AniDB anime-titles dump.
+func ParseAnimeTitles(r io.Reader) ([]AnimeTitle, error) {
+	doc := &animeTitlesXML{}
+	err := xml.NewDecoder(r).Decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode XML: %v", err)
+	}
+
+	var titles []AnimeTitle
+	for _, a := range doc.Anime {
+		for _, t := range a.Titles {
+			titles = append(titles, AnimeTitle{
+				AniDBID:  a.AID,
+				Type:     t.Type,
+				Language: t.Language,
+				Title:    t.Title,
+			})
+		}
+	}
+
+	return titles, nil
+}
+
+// ParseAnimeTitlesDAT parses the "aid|type|language|title" DAT AniDB
+// anime-titles dump.
+func ParseAnimeTitlesDAT(r io.Reader) ([]AnimeTitle, error) {
+	var titles []AnimeTitle
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, "|", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected 4 fields: %q", line, text)
+		}
+		aid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid anime ID: %v", line, err)
+		}
+		typ, ok := datTitleTypes[fields[1]]
+		if !ok {
+			typ = fields[1]
+		}
+
+		titles = append(titles, AnimeTitle{
+			AniDBID:  aid,
+			Type:     typ,
+			Language: fields[2],
+			Title:    fields[3],
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
@@ -0,0 +1,170 @@
+package xem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Origin types resolved through the anime-offline-database
+const (
+	MyAnimeList = "mal"
+	Kitsu       = "kitsu"
+	AniList     = "anilist"
+)
+
+// animeDBHosts maps anime-offline-database source hosts to origins.
+var animeDBHosts = map[string]string{
+	"anidb.net":       AniDB,
+	"myanimelist.net": MyAnimeList,
+	"kitsu.io":        Kitsu,
+	"kitsu.app":       Kitsu,
+	"anilist.co":      AniList,
+}
+
+// AnimeDatabase links the IDs of anime sites, as loaded from the
+// anime-offline-database JSON file.
+type AnimeDatabase struct {
+	Entries []AnimeEntry
+
+	ids map[string]map[string]int
+}
+
+// AnimeEntry is a single anime of the anime-offline-database.
+type AnimeEntry struct {
+	Title    string
+	Type     string
+	Episodes int
+	Synonyms []string
+
+	// IDs by origin, for the sites supported by this package
+	IDs map[string]string
+}
+
+type animeDBJSON struct {
+	Data []struct {
+		Sources  []string `json:"sources"`
+		Title    string   `json:"title"`
+		Type     string   `json:"type"`
+		Episodes int      `json:"episodes"`
+		Synonyms []string `json:"synonyms"`
+	} `json:"data"`
+}
+
+// LoadAnimeDatabase loads the anime-offline-database JSON from a local file.
+func LoadAnimeDatabase(path string) (*AnimeDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseAnimeDatabase(f)
+}
+
+// ParseAnimeDatabase parses the anime-offline-database JSON.
+func ParseAnimeDatabase(r io.Reader) (*AnimeDatabase, error) {
+	doc := &animeDBJSON{}
+	err := json.NewDecoder(r).Decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode JSON: %v", err)
+	}
+
+	d := &AnimeDatabase{ids: make(map[string]map[string]int)}
+	for _, a := range doc.Data {
+		e := AnimeEntry{
+			Title:    a.Title,
+			Type:     a.Type,
+			Episodes: a.Episodes,
+			Synonyms: a.Synonyms,
+			IDs:      make(map[string]string),
+		}
+		for _, s := range a.Sources {
+			origin, id, ok := parseAnimeDBSource(s)
+			if !ok {
+				continue
+			}
+			e.IDs[origin] = id
+			if d.ids[origin] == nil {
+				d.ids[origin] = make(map[string]int)
+			}
+			d.ids[origin][id] = len(d.Entries)
+		}
+		d.Entries = append(d.Entries, e)
+	}
+
+	return d, nil
+}
+
+// parseAnimeDBSource returns the origin and ID of a source URL such as
+// "https://myanimelist.net/anime/1".
+func parseAnimeDBSource(s string) (origin, id string, ok bool) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", false
+	}
+	origin, ok = animeDBHosts[strings.TrimPrefix(u.Host, "www.")]
+	if !ok {
+		return "", "", false
+	}
+	id = u.Path[strings.LastIndex(u.Path, "/")+1:]
+	if id == "" {
+		return "", "", false
+	}
+	return origin, id, true
+}
+
+// Lookup returns the entry with the given origin ID.
+func (d *AnimeDatabase) Lookup(origin, id string) (AnimeEntry, bool) {
+	i, ok := d.ids[origin][id]
+	if !ok {
+		return AnimeEntry{}, false
+	}
+	return d.Entries[i], true
+}
+
+// ID converts an ID between origins, for example from MyAnimeList to AniDB.
+func (d *AnimeDatabase) ID(origin, id, target string) (string, bool) {
+	e, ok := d.Lookup(origin, id)
+	if !ok {
+		return "", false
+	}
+	targetID, ok := e.IDs[target]
+	return targetID, ok
+}
+
+// Episodes returns the number of episodes of an AniDB anime, or 0 if unknown.
+// It can be used as AnimeList.Episodes.
+func (d *AnimeDatabase) Episodes(anidbID int) int {
+	e, _ := d.Lookup(AniDB, strconv.Itoa(anidbID))
+	return e.Episodes
+}
+
+// Resolve wraps a Source so that mappings can be retrieved by MyAnimeList,
+// Kitsu or AniList IDs, which are resolved to AniDB IDs. Other origins are
+// passed through unchanged.
+func (d *AnimeDatabase) Resolve(s Source) Source {
+	return &resolvingSource{db: d, source: s}
+}
+
+type resolvingSource struct {
+	db     *AnimeDatabase
+	source Source
+}
+
+func (r *resolvingSource) All(origin, id string) ([]Mapping, error) {
+	switch origin {
+	case MyAnimeList, Kitsu, AniList:
+		anidbID, ok := r.db.ID(origin, id, AniDB)
+		if !ok {
+			return nil, fmt.Errorf("%w: no AniDB ID for %v %v", ErrNotFound, origin, id)
+		}
+		return r.source.All(AniDB, anidbID)
+	default:
+		return r.source.All(origin, id)
+	}
+}
@@ -0,0 +1,204 @@
+package xem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// absoluteSeason is used by Anime-Lists in place of a season number when
+// TVDB uses absolute numbering for a show.
+const absoluteSeason = "a"
+
+// AnimeList holds the community Anime-Lists (ScudLee) AniDB to TVDB mappings.
+// It implements Source, so it can be used in place of or alongside a Client.
+type AnimeList struct {
+	Anime []Anime
+
+	// Episodes returns the number of regular episodes of an AniDB anime, or 0
+	// if unknown. AnimeDatabase.Episodes can be used.
+	Episodes func(anidbID int) int
+}
+
+// Anime is a single entry of the Anime-Lists XML.
+type Anime struct {
+	AniDBID       int            `xml:"anidbid,attr"`
+	TVDBID        string         `xml:"tvdbid,attr"`
+	DefaultSeason string         `xml:"defaulttvdbseason,attr"`
+	EpisodeOffset string         `xml:"episodeoffset,attr"`
+	Name          string         `xml:"name"`
+	Mappings      []AnimeMapping `xml:"mapping-list>mapping"`
+}
+
+// AnimeMapping maps an AniDB season to a TVDB season, either by episode range
+// and offset or by an explicit ";anidb-tvdb;" episode list.
+type AnimeMapping struct {
+	AniDBSeason int    `xml:"anidbseason,attr"`
+	TVDBSeason  string `xml:"tvdbseason,attr"`
+	Start       int    `xml:"start,attr"`
+	End         int    `xml:"end,attr"`
+	Offset      int    `xml:"offset,attr"`
+	Episodes    string `xml:",chardata"`
+}
+
+type animeListXML struct {
+	Anime []Anime `xml:"anime"`
+}
+
+// ParseAnimeList parses an Anime-Lists XML document. Most entries only give a
+// default season and offset, so the number of regular episodes of each anime
+// must be provided by episodes.
+func ParseAnimeList(r io.Reader, episodes func(anidbID int) int) (*AnimeList, error) {
+	if episodes == nil {
+		return nil, fmt.Errorf("episode count provider required")
+	}
+
+	doc := &animeListXML{}
+	err := xml.NewDecoder(r).Decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode XML: %v", err)
+	}
+
+	return &AnimeList{Anime: doc.Anime, Episodes: episodes}, nil
+}
+
+// All retrieves all mappings for the given AniDB or TVDB ID, in the same
+// format as Client.All. Entries with an unknown episode count contribute only
+// their explicit mappings. An error wrapping ErrNotFound is returned if no
+// entry has TVDB episodes, and an error if nothing could be mapped because an
+// episode count is unknown.
+func (l *AnimeList) All(origin, id string) ([]Mapping, error) {
+	var all []Mapping
+	unknown := 0
+	for _, a := range l.Anime {
+		switch {
+		case origin == AniDB && strconv.Itoa(a.AniDBID) == id:
+		case origin == TVDB && a.TVDBID == id:
+		default:
+			continue
+		}
+		if _, err := strconv.Atoi(a.TVDBID); err != nil {
+			// Movies, OVAs and unknown shows have no TVDB episodes
+			continue
+		}
+
+		// Airing and upcoming anime may not have an episode count yet, in
+		// which case only their explicit mappings are used
+		count := 0
+		if l.Episodes != nil {
+			count = l.Episodes(a.AniDBID)
+		}
+		if _, ok := tvdbEpisode(a.DefaultSeason, 1); ok && count == 0 && unknown == 0 {
+			unknown = a.AniDBID
+		}
+		all = append(all, a.Mapping(count)...)
+	}
+	if len(all) == 0 && unknown != 0 {
+		return nil, fmt.Errorf("unknown episode count for anidb %d", unknown)
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("%w: no anime-list entry for %v %v", ErrNotFound, origin, id)
+	}
+
+	return all, nil
+}
+
+// Mapping converts the entry to mappings between AniDB and TVDB. Regular
+// episodes 1 to count are mapped using the default season and offset, unless
+// an explicit mapping overrides them. Episodes without a TVDB counterpart are
+// omitted.
+func (a Anime) Mapping(count int) []Mapping {
+	if _, err := strconv.Atoi(a.TVDBID); err != nil {
+		// Movies, OVAs and unknown shows have no TVDB episodes
+		return nil
+	}
+
+	type key struct{ season, episode int }
+	mapped := make(map[key]Episode)
+	var order []key
+	add := func(season, episode int, tvdb Episode) {
+		k := key{season, episode}
+		if _, ok := mapped[k]; !ok {
+			order = append(order, k)
+		}
+		mapped[k] = tvdb
+	}
+
+	offset, _ := strconv.Atoi(a.EpisodeOffset)
+	for n := 1; n <= count; n++ {
+		if tvdb, ok := tvdbEpisode(a.DefaultSeason, n+offset); ok {
+			add(1, n, tvdb)
+		}
+	}
+
+	// Ranges are applied before explicit episode lists, which take precedence
+	for _, m := range a.Mappings {
+		if m.Start == 0 && m.End == 0 {
+			continue
+		}
+		for n := m.Start; n <= m.End; n++ {
+			if tvdb, ok := tvdbEpisode(m.TVDBSeason, n+m.Offset); ok {
+				add(m.AniDBSeason, n, tvdb)
+			}
+		}
+	}
+	for _, m := range a.Mappings {
+		for _, pair := range strings.Split(m.Episodes, ";") {
+			parts := strings.SplitN(strings.TrimSpace(pair), "-", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			n, err := strconv.Atoi(parts[0])
+			if err != nil {
+				continue
+			}
+			// Multiple TVDB episodes are joined with "+"; use the first
+			ep, err := strconv.Atoi(strings.SplitN(parts[1], "+", 2)[0])
+			if err != nil {
+				continue
+			}
+			if tvdb, ok := tvdbEpisode(m.TVDBSeason, ep); ok {
+				add(m.AniDBSeason, n, tvdb)
+			}
+		}
+	}
+
+	var all []Mapping
+	for _, k := range order {
+		tvdb := mapped[k]
+		if tvdb.IsZero() {
+			continue
+		}
+		anidb := Episode{Season: k.season, Episode: k.episode}
+		if k.season == 1 {
+			anidb.Absolute = k.episode
+		}
+		all = append(all, Mapping{AniDB: anidb, TVDB: tvdb})
+	}
+
+	return all
+}
+
+// tvdbEpisode returns the nth episode of an Anime-Lists TVDB season, which
+// may be absoluteSeason. Episode numbers below 1 are returned as a zero
+// Episode, which marks the AniDB episode as unmapped.
+func tvdbEpisode(season string, n int) (Episode, bool) {
+	sn := 0
+	if season != absoluteSeason {
+		var err error
+		sn, err = strconv.Atoi(season)
+		if err != nil {
+			return Episode{}, false
+		}
+	}
+	switch {
+	case n < 1:
+		return Episode{}, true
+	case season == absoluteSeason:
+		return Episode{Absolute: n}, true
+	default:
+		return Episode{Season: sn, Episode: n}, true
+	}
+}
@@ -0,0 +1,165 @@
+package xem
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// cacheEntryOverhead approximates the memory used by an entry besides its key
+// and data.
+const cacheEntryOverhead = 128
+
+// CacheStats are statistics of a cache store
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Entries   int
+	Bytes     int64
+}
+
+// BoundedStore is an in-memory CacheStore bounded by the total size of its
+// entries, evicting the least recently used entries first. Sizes are estimated
+// from the decoded payloads of entries.
+type BoundedStore struct {
+	MaxBytes int64
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+// NewBoundedStore creates an empty store holding at most maxBytes
+func NewBoundedStore(maxBytes int64) *BoundedStore {
+	return &BoundedStore{
+		MaxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// boundedEntry is an entry of a BoundedStore and its estimated size
+type boundedEntry struct {
+	entry *CacheEntry
+	size  int64
+}
+
+// entrySize estimates the memory used by an entry once its data is decoded
+func entrySize(e *CacheEntry) int64 {
+	size := int64(len(e.Key) + len(e.NotFound) + cacheEntryOverhead)
+	var v interface{}
+	if len(e.Data) == 0 || json.Unmarshal(e.Data, &v) != nil {
+		return size + int64(len(e.Data))
+	}
+	return size + decodedSize(v)
+}
+
+// decodedSize estimates the memory used by a decoded JSON value, including
+// the interface holding it.
+func decodedSize(v interface{}) int64 {
+	const word = 8
+	size := int64(2 * word)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		size += 6 * word
+		for k, x := range v {
+			size += 2*word + int64(len(k)) + decodedSize(x)
+		}
+	case []interface{}:
+		size += 3 * word
+		for _, x := range v {
+			size += decodedSize(x)
+		}
+	case string:
+		size += 2*word + int64(len(v))
+	case float64:
+		size += word
+	}
+	return size
+}
+
+// Get implements CacheStore. Expired entries are removed and count as misses.
+func (s *BoundedStore) Get(key string) (*CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		s.stats.Misses++
+		return nil, false
+	}
+	e := el.Value.(*boundedEntry).entry
+	if !time.Now().Before(e.Expires) {
+		s.remove(el)
+		s.stats.Misses++
+		return nil, false
+	}
+
+	s.order.MoveToFront(el)
+	s.stats.Hits++
+	return e, true
+}
+
+// Set implements CacheStore. Entries larger than MaxBytes are not stored.
+func (s *BoundedStore) Set(e *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[e.Key]; ok {
+		s.remove(el)
+	}
+	size := entrySize(e)
+	if size > s.MaxBytes {
+		return nil
+	}
+
+	for s.stats.Bytes+size > s.MaxBytes {
+		s.remove(s.order.Back())
+		s.stats.Evictions++
+	}
+	s.entries[e.Key] = s.order.PushFront(&boundedEntry{e, size})
+	s.stats.Entries++
+	s.stats.Bytes += size
+	return nil
+}
+
+// Delete implements CacheStore
+func (s *BoundedStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Entries implements CacheStore
+func (s *BoundedStore) Entries() ([]*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]*CacheEntry, 0, len(s.entries))
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(*boundedEntry).entry)
+	}
+	return entries, nil
+}
+
+// Stats returns the statistics of the store
+func (s *BoundedStore) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+func (s *BoundedStore) remove(el *list.Element) {
+	e := s.order.Remove(el).(*boundedEntry)
+	delete(s.entries, e.entry.Key)
+	s.stats.Entries--
+	s.stats.Bytes -= e.size
+}
@@ -0,0 +1,458 @@
+package xem
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default cache lifetimes
+const (
+	defaultCacheTTL         = 24 * time.Hour
+	defaultCacheNegativeTTL = 6 * time.Hour
+)
+
+// Cached endpoints, for use in TTL policies
+const (
+	EndpointAll    = defaultAllEndpoint
+	EndpointNames  = defaultNamesEndpoint
+	EndpointSingle = defaultSingleEndpoint
+)
+
+// CacheEntry is a cached result
+type CacheEntry struct {
+	Key     string          `json:"key"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Stored  time.Time       `json:"stored"`
+	Expires time.Time       `json:"expires"`
+
+	// Negative is set for results with no mapping. NotFound holds the message
+	// of the ErrNotFound error returned, if any.
+	Negative bool   `json:"negative,omitempty"`
+	NotFound string `json:"notFound,omitempty"`
+}
+
+// CacheStore stores cache entries by key
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(e *CacheEntry) error
+	Delete(key string) error
+
+	// Entries lists all entries, including expired ones, without affecting
+	// statistics or eviction order
+	Entries() ([]*CacheEntry, error)
+}
+
+// Cache caches the results of a Client. Results with no mapping, such as
+// shows unknown to XEM, are cached separately for NegativeTTL. Failed
+// requests are never cached.
+type Cache struct {
+	Client      *Client
+	Store       CacheStore
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	// Policies override TTL and NegativeTTL for matching requests
+	Policies []TTLPolicy
+}
+
+// TTLPolicy sets the cache lifetimes of requests matching its endpoint, origin
+// and show ID. Empty fields match any request, and zero lifetimes fall back to
+// the cache defaults. When several policies match, the most specific one wins:
+// a show ID outweighs an origin, which outweighs an endpoint.
+type TTLPolicy struct {
+	Endpoint string
+	Origin   string
+	ID       string
+
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
+// matches returns the specificity of the policy for the request, or -1 if it
+// does not apply.
+func (p TTLPolicy) matches(endpoint, origin, id string) int {
+	n := 0
+	for i, f := range [][2]string{{p.Endpoint, endpoint}, {p.Origin, origin}, {p.ID, id}} {
+		switch f[0] {
+		case "":
+		case f[1]:
+			n += 1 << uint(i)
+		default:
+			return -1
+		}
+	}
+	return n
+}
+
+// ttl returns the lifetime of a result of the given request
+func (c *Cache) ttl(endpoint string, vals url.Values, negative bool) time.Duration {
+	ttl, negativeTTL := c.TTL, c.NegativeTTL
+	best := -1
+	for _, p := range c.Policies {
+		n := p.matches(endpoint, vals.Get("origin"), vals.Get("id"))
+		if n <= best {
+			continue
+		}
+		best = n
+		ttl, negativeTTL = c.TTL, c.NegativeTTL
+		if p.TTL != 0 {
+			ttl = p.TTL
+		}
+		if p.NegativeTTL != 0 {
+			negativeTTL = p.NegativeTTL
+		}
+	}
+
+	if negative {
+		return negativeTTL
+	}
+	return ttl
+}
+
+// NewCache creates a cache for the given client, using an in-memory store if
+// store is nil
+func NewCache(client *Client, store CacheStore) *Cache {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	return &Cache{
+		Client:      client,
+		Store:       store,
+		TTL:         defaultCacheTTL,
+		NegativeTTL: defaultCacheNegativeTTL,
+	}
+}
+
+// All retrieves all mappings from the given origin and ID, from the cache if
+// possible. It implements Source.
+func (c *Cache) All(origin, id string) ([]Mapping, error) {
+	vals := make(url.Values)
+	vals.Set("origin", origin)
+	vals.Set("id", id)
+	key := cacheKey(EndpointAll, vals)
+
+	var all []Mapping
+	if hit, err := c.get(key, &all); hit {
+		return all, err
+	}
+
+	all, err := c.Client.All(origin, id)
+	return all, c.set(EndpointAll, vals, all, len(all) == 0, err)
+}
+
+// Names retrieves the names of the given origin and language, from the cache
+// if possible.
+func (c *Cache) Names(origin, lang string) (map[string]([]map[string]int), error) {
+	vals := make(url.Values)
+	vals.Set("origin", origin)
+	vals.Set("language", lang)
+	key := cacheKey(EndpointNames, vals)
+
+	var names map[string]([]map[string]int)
+	if hit, err := c.get(key, &names); hit {
+		return names, err
+	}
+
+	names, err := c.Client.Names(origin, lang)
+	return names, c.set(EndpointNames, vals, names, len(names) == 0, err)
+}
+
+// Single retrieves the mapping of a single episode, from the cache if
+// possible.
+func (c *Cache) Single(origin, id string, ep Episode, destination string) (Mapping, error) {
+	vals := make(url.Values)
+	vals.Set("origin", origin)
+	vals.Set("id", id)
+	vals.Set("season", strconv.Itoa(ep.Season))
+	vals.Set("episode", strconv.Itoa(ep.Episode))
+	vals.Set("absolute", strconv.Itoa(ep.Absolute))
+	vals.Set("destination", destination)
+	key := cacheKey(EndpointSingle, vals)
+
+	var m Mapping
+	if hit, err := c.get(key, &m); hit {
+		return m, err
+	}
+
+	m, err := c.Client.Single(origin, id, ep, destination)
+	return m, c.set(EndpointSingle, vals, m, len(m) == 0, err)
+}
+
+// cacheKey returns the cache key of a request
+func cacheKey(endpoint string, vals url.Values) string {
+	return endpoint + "?" + vals.Encode()
+}
+
+// get decodes an unexpired entry into result. It reports whether the entry
+// was found, along with the error to return for it.
+func (c *Cache) get(key string, result interface{}) (bool, error) {
+	e, ok := c.Store.Get(key)
+	if !ok || !time.Now().Before(e.Expires) {
+		return false, nil
+	}
+
+	if e.NotFound != "" {
+		return true, fmt.Errorf("%w: %v", ErrNotFound, e.NotFound)
+	}
+	if len(e.Data) == 0 {
+		return true, nil
+	}
+	err := json.Unmarshal(e.Data, result)
+	if err != nil {
+		// Treat undecodable entries as missing
+		return false, nil
+	}
+	return true, nil
+}
+
+// set caches the result of a request and returns its error. Only successful
+// results and ErrNotFound errors are cached. The cache is best effort, so
+// store errors are ignored.
+func (c *Cache) set(endpoint string, vals url.Values, result interface{}, empty bool, err error) error {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	e := &CacheEntry{
+		Key:      cacheKey(endpoint, vals),
+		Stored:   now,
+		Negative: empty || err != nil,
+	}
+	e.Expires = now.Add(c.ttl(endpoint, vals, e.Negative))
+
+	if err != nil {
+		e.NotFound = strings.TrimPrefix(err.Error(), ErrNotFound.Error()+": ")
+	} else {
+		data, jsonErr := json.Marshal(result)
+		if jsonErr != nil {
+			return err
+		}
+		e.Data = data
+	}
+
+	c.Store.Set(e)
+	return err
+}
+
+// MemoryStore is an unbounded in-memory CacheStore
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*CacheEntry)}
+}
+
+// Get implements CacheStore
+func (s *MemoryStore) Get(key string) (*CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	return e, ok
+}
+
+// Set implements CacheStore
+func (s *MemoryStore) Set(e *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[e.Key] = e
+	return nil
+}
+
+// Delete implements CacheStore
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Entries implements CacheStore
+func (s *MemoryStore) Entries() ([]*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]*CacheEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// DiskStore is a CacheStore keeping one gzip compressed JSON file per entry
+// in a directory. Uncompressed entries written by earlier versions are still
+// read, and are compressed when read or by Migrate.
+type DiskStore struct {
+	Dir string
+}
+
+// NewDiskStore creates a store in the given directory
+func NewDiskStore(dir string) (*DiskStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskStore{Dir: dir}, nil
+}
+
+// Get implements CacheStore
+func (s *DiskStore) Get(key string) (*CacheEntry, bool) {
+	e, err := readCacheFile(s.path(key))
+	if os.IsNotExist(err) {
+		e, err = readCacheFile(s.legacyPath(key))
+		if err == nil && e.Key == key {
+			s.Set(e)
+		}
+	}
+	if err != nil || e.Key != key {
+		return nil, false
+	}
+	return e, true
+}
+
+// Set implements CacheStore
+func (s *DiskStore) Set(e *CacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, err = gz.Write(data)
+	if err != nil {
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(s.path(e.Key), buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return removeIfExists(s.legacyPath(e.Key))
+}
+
+// Delete implements CacheStore
+func (s *DiskStore) Delete(key string) error {
+	err := removeIfExists(s.path(key))
+	if err != nil {
+		return err
+	}
+	return removeIfExists(s.legacyPath(key))
+}
+
+// Entries implements CacheStore. Unreadable files are skipped.
+func (s *DiskStore) Entries() ([]*CacheEntry, error) {
+	compressed, err := filepath.Glob(filepath.Join(s.Dir, "*.json.gz"))
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*CacheEntry
+	seen := make(map[string]bool)
+	for _, f := range append(compressed, legacy...) {
+		e, err := readCacheFile(f)
+		if err != nil || e.Key == "" || seen[e.Key] {
+			continue
+		}
+		seen[e.Key] = true
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Migrate compresses all uncompressed entries
+func (s *DiskStore) Migrate() error {
+	legacy, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range legacy {
+		e, err := readCacheFile(f)
+		if err != nil || e.Key == "" {
+			continue
+		}
+		if _, err := os.Stat(s.path(e.Key)); err == nil {
+			// Already migrated; the compressed entry is newer
+			err = removeIfExists(f)
+		} else {
+			err = s.Set(e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DiskStore) path(key string) string {
+	return s.legacyPath(key) + ".gz"
+}
+
+func (s *DiskStore) legacyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readCacheFile reads an entry, decompressing it if its name ends in ".gz"
+func readCacheFile(path string) (*CacheEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	e := &CacheEntry{}
+	err = json.NewDecoder(r).Decode(e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func removeIfExists(path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package xem
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheInfo describes a cached entry
+type CacheInfo struct {
+	Key      string
+	Endpoint string
+	Origin   string
+	ID       string
+	Age      time.Duration
+	Size     int64
+	Negative bool
+	Expired  bool
+}
+
+// parseCacheKey splits a cache key into its endpoint and query values
+func parseCacheKey(key string) (string, url.Values) {
+	i := strings.Index(key, "?")
+	if i < 0 {
+		return key, url.Values{}
+	}
+	vals, _ := url.ParseQuery(key[i+1:])
+	return key[:i], vals
+}
+
+// List describes all cached entries, sorted by key
+func (c *Cache) List() ([]CacheInfo, error) {
+	entries, err := c.Store.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	infos := make([]CacheInfo, len(entries))
+	for i, e := range entries {
+		endpoint, vals := parseCacheKey(e.Key)
+		infos[i] = CacheInfo{
+			Key:      e.Key,
+			Endpoint: endpoint,
+			Origin:   vals.Get("origin"),
+			ID:       vals.Get("id"),
+			Age:      now.Sub(e.Stored),
+			Size:     entrySize(e),
+			Negative: e.Negative,
+			Expired:  !now.Before(e.Expires),
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+
+	return infos, nil
+}
+
+// Stats returns the statistics of the cache store. Hits, misses and
+// evictions are only known for stores that count them, such as BoundedStore.
+func (c *Cache) Stats() (CacheStats, error) {
+	if s, ok := c.Store.(interface{ Stats() CacheStats }); ok {
+		return s.Stats(), nil
+	}
+
+	infos, err := c.List()
+	if err != nil {
+		return CacheStats{}, err
+	}
+	stats := CacheStats{Entries: len(infos)}
+	for _, info := range infos {
+		stats.Bytes += info.Size
+	}
+	return stats, nil
+}
+
+// Invalidate removes the cached entries of an origin and ID from every
+// endpoint. An empty ID removes all entries of the origin.
+func (c *Cache) Invalidate(origin, id string) error {
+	return c.remove(func(info CacheInfo) bool {
+		return info.Origin == origin && (id == "" || info.ID == id)
+	})
+}
+
+// InvalidateEndpoint removes all cached entries of an endpoint, such as
+// EndpointNames
+func (c *Cache) InvalidateEndpoint(endpoint string) error {
+	return c.remove(func(info CacheInfo) bool {
+		return info.Endpoint == endpoint
+	})
+}
+
+// Purge removes all cached entries
+func (c *Cache) Purge() error {
+	return c.remove(func(CacheInfo) bool { return true })
+}
+
+func (c *Cache) remove(match func(CacheInfo) bool) error {
+	infos, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		if !match(info) {
+			continue
+		}
+		err = c.Store.Delete(info.Key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
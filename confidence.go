@@ -0,0 +1,54 @@
+package xem
+
+import "math"
+
+// Reason codes explaining a confidence score
+const (
+	ReasonDirect         = "direct"
+	ReasonTransitive     = "transitive"
+	ReasonAmbiguous      = "ambiguous"
+	ReasonNotFound       = "not-found"
+	ReasonExactName      = "exact-name"
+	ReasonNormalizedName = "normalized-name"
+	ReasonFuzzyName      = "fuzzy-name"
+	ReasonAlternateTitle = "alternate-title"
+	ReasonMultipleShows  = "multiple-shows"
+)
+
+// Confidence penalties
+const (
+	transitivePenalty     = 0.8
+	ambiguityPenalty      = 0.5
+	normalizedNamePenalty = 0.9
+	fuzzyNamePenalty      = 0.7
+	alternateTitlePenalty = 0.9
+	multipleShowsPenalty  = 0.5
+)
+
+// Confidence of a result, scored from 0 to 1, with the reasons for the score
+type Confidence struct {
+	Score   float64
+	Reasons []string
+}
+
+// translationConfidence scores a translation: direct mappings are trusted,
+// while each additional step and each ambiguity reduce the score.
+func translationConfidence(x *Explanation) Confidence {
+	if !x.Found {
+		return Confidence{Score: 0, Reasons: []string{ReasonNotFound}}
+	}
+
+	c := Confidence{Score: 1}
+	if len(x.Steps) <= 1 {
+		c.Reasons = append(c.Reasons, ReasonDirect)
+	} else {
+		c.Score *= math.Pow(transitivePenalty, float64(len(x.Steps)-1))
+		c.Reasons = append(c.Reasons, ReasonTransitive)
+	}
+	if len(x.Ambiguities) > 0 {
+		c.Score *= math.Pow(ambiguityPenalty, float64(len(x.Ambiguities)))
+		c.Reasons = append(c.Reasons, ReasonAmbiguous)
+	}
+
+	return c
+}
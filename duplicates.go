@@ -0,0 +1,53 @@
+package xem
+
+import "sort"
+
+// EpisodeRef is an item, such as a file, numbered as an episode of an origin
+type EpisodeRef struct {
+	Key     string
+	Origin  string
+	Episode Episode
+}
+
+// DuplicateGroup is a set of items resolving to the same episode
+type DuplicateGroup struct {
+	Episode Episode
+	Refs    []EpisodeRef
+}
+
+// FindDuplicates translates every item to the target origin and returns the
+// groups of items resolving to the same episode, for example a scene S02E05
+// and an absolute E30. Items that cannot be translated are returned as
+// unresolved.
+func FindDuplicates(all []Mapping, refs []EpisodeRef, target string) (groups []DuplicateGroup, unresolved []EpisodeRef) {
+	byEpisode := make(map[Episode][]EpisodeRef)
+	for _, ref := range refs {
+		ep, ok := Translate(all, ref.Origin, ref.Episode, target)
+		if !ok {
+			unresolved = append(unresolved, ref)
+			continue
+		}
+		ep = canonicalEpisode(all, target, ep)
+		byEpisode[ep] = append(byEpisode[ep], ref)
+	}
+
+	for ep, list := range byEpisode {
+		if len(list) > 1 {
+			groups = append(groups, DuplicateGroup{Episode: ep, Refs: list})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Episode.Less(groups[j].Episode) })
+
+	return groups, unresolved
+}
+
+// canonicalEpisode returns the full numbering of an episode of an origin, as
+// found in the mappings, so that partially numbered episodes compare equal.
+func canonicalEpisode(all []Mapping, origin string, ep Episode) Episode {
+	for _, m := range all {
+		if got, ok := m[origin]; ok && got.Matches(ep) {
+			return got
+		}
+	}
+	return ep
+}
@@ -0,0 +1,188 @@
+package xem
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Accepted episode string formats
+var (
+	seasonEpisodeRegexp = regexp.MustCompile(`^[Ss](\d+)[Ee](\d+)$`)
+	crossEpisodeRegexp  = regexp.MustCompile(`^(\d+)[Xx](\d+)$`)
+	absoluteRegexp      = regexp.MustCompile(`^[Ee](\d+)$`)
+	combinedRegexp      = regexp.MustCompile(`^(\S+)\s*\(\s*([Ee]\d+)\s*\)$`)
+)
+
+// ParseEpisode parses an episode from a string such as "S02E05", "2x05" or
+// "E137". A season episode followed by an absolute one, as produced by
+// Episode.String (e.g. "S02E05 (E137)"), is also accepted.
+func ParseEpisode(s string) (Episode, error) {
+	s = strings.TrimSpace(s)
+
+	if m := combinedRegexp.FindStringSubmatch(s); m != nil {
+		e, err := ParseEpisode(m[1])
+		if err != nil || !e.HasSeason() {
+			return Episode{}, fmt.Errorf("invalid episode: %q", s)
+		}
+		abs, err := ParseEpisode(m[2])
+		if err != nil {
+			return Episode{}, err
+		}
+		e.Absolute = abs.Absolute
+		return e, nil
+	}
+
+	if m := seasonEpisodeRegexp.FindStringSubmatch(s); m != nil {
+		return parseSeasonEpisode(s, m[1], m[2])
+	}
+	if m := crossEpisodeRegexp.FindStringSubmatch(s); m != nil {
+		return parseSeasonEpisode(s, m[1], m[2])
+	}
+	if m := absoluteRegexp.FindStringSubmatch(s); m != nil {
+		abs, err := strconv.Atoi(m[1])
+		if err != nil || abs == 0 {
+			return Episode{}, fmt.Errorf("invalid episode: %q", s)
+		}
+		return Episode{Absolute: abs}, nil
+	}
+
+	return Episode{}, fmt.Errorf("invalid episode: %q", s)
+}
+
+func parseSeasonEpisode(s, season, episode string) (Episode, error) {
+	sn, err := strconv.Atoi(season)
+	if err != nil {
+		return Episode{}, fmt.Errorf("invalid episode: %q", s)
+	}
+	ep, err := strconv.Atoi(episode)
+	if err != nil || ep == 0 {
+		return Episode{}, fmt.Errorf("invalid episode: %q", s)
+	}
+	return Episode{Season: sn, Episode: ep}, nil
+}
+
+// HasSeason reports whether the season and episode numbers are known.
+// Season 0 is used for specials, so only the episode number is checked.
+func (e Episode) HasSeason() bool {
+	return e.Episode > 0
+}
+
+// HasAbsolute reports whether the absolute episode number is known. XEM uses
+// 0 for missing absolute numbers.
+func (e Episode) HasAbsolute() bool {
+	return e.Absolute > 0
+}
+
+// IsZero reports whether no numbering is known for the episode.
+func (e Episode) IsZero() bool {
+	return !e.HasSeason() && !e.HasAbsolute()
+}
+
+// Matches reports whether e and o refer to the same episode, comparing only
+// the numbers known for both.
+func (e Episode) Matches(o Episode) bool {
+	switch {
+	case e.HasSeason() && o.HasSeason():
+		return e.Season == o.Season && e.Episode == o.Episode
+	case e.HasAbsolute() && o.HasAbsolute():
+		return e.Absolute == o.Absolute
+	default:
+		return false
+	}
+}
+
+// Compare returns -1, 0 or +1 depending on whether e sorts before, equal to
+// or after o. Episodes are ordered by season, then episode, then absolute
+// number.
+func (e Episode) Compare(o Episode) int {
+	switch {
+	case e.Season != o.Season:
+		return compareInt(e.Season, o.Season)
+	case e.Episode != o.Episode:
+		return compareInt(e.Episode, o.Episode)
+	default:
+		return compareInt(e.Absolute, o.Absolute)
+	}
+}
+
+// Less reports whether e sorts before o.
+func (e Episode) Less(o Episode) bool {
+	return e.Compare(o) < 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// String formats the episode as "S02E05", "E137" or "S02E05 (E137)",
+// depending on which numbers are known.
+func (e Episode) String() string {
+	switch {
+	case e.HasSeason() && e.HasAbsolute():
+		return fmt.Sprintf("S%02dE%02d (E%02d)", e.Season, e.Episode, e.Absolute)
+	case e.HasSeason():
+		return fmt.Sprintf("S%02dE%02d", e.Season, e.Episode)
+	case e.HasAbsolute():
+		return fmt.Sprintf("E%02d", e.Absolute)
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler. Unknown episodes are
+// marshaled as an empty string.
+func (e Episode) MarshalText() ([]byte, error) {
+	if e.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *Episode) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*e = Episode{}
+		return nil
+	}
+	ep, err := ParseEpisode(string(text))
+	if err != nil {
+		return err
+	}
+	*e = ep
+	return nil
+}
+
+// episodeJSON has the same fields as Episode, but none of its methods.
+type episodeJSON Episode
+
+// MarshalJSON implements json.Marshaler. Episodes are marshaled as objects in
+// the same format used by the XEM API.
+func (e Episode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(episodeJSON(e))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Both the XEM API object format
+// and the string formats accepted by ParseEpisode are supported.
+func (e *Episode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return e.UnmarshalText([]byte(s))
+	}
+
+	var ep episodeJSON
+	if err := json.Unmarshal(data, &ep); err != nil {
+		return err
+	}
+	*e = Episode(ep)
+	return nil
+}
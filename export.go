@@ -0,0 +1,215 @@
+package xem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Exporter writes mappings and names as pretty-printed JSON files in a
+// directory tree, one directory per show. Output is sorted so that unchanged
+// data produces identical files, and files are only rewritten when their
+// content changes, which keeps version control diffs minimal.
+type Exporter struct {
+	Dir string
+}
+
+// ExportMappings writes the mappings of a show to
+// <dir>/<origin>/<id>/mappings.json, sorted by the episode of the origin.
+func (e *Exporter) ExportMappings(origin, id string, all []Mapping) error {
+	sorted := make([]Mapping, len(all))
+	copy(sorted, all)
+	keys := make([]string, len(sorted))
+	for i, m := range sorted {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		keys[i] = string(data)
+	}
+	sort.Sort(mappingsByOrigin{origin, sorted, keys})
+
+	path, err := e.showPath(origin, id, "mappings.json")
+	if err != nil {
+		return err
+	}
+	return e.write(path, sorted)
+}
+
+// ExportNames writes the result of Client.Names for an origin and language to
+// <dir>/<origin>/<id>/names-<lang>.json for each show, sorted by name and
+// season. Files of the language for shows no longer named are removed.
+func (e *Exporter) ExportNames(origin, lang string, names map[string]([]map[string]int)) error {
+	file := namesFile(lang)
+	shows := make(map[string]bool)
+	for id, list := range names {
+		sorted := make([]map[string]int, 0, len(list))
+		for _, m := range list {
+			for name, season := range m {
+				sorted = append(sorted, map[string]int{name: season})
+			}
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			ni, nj := firstName(sorted[i]), firstName(sorted[j])
+			if ni != nj {
+				return ni < nj
+			}
+			return sorted[i][ni] < sorted[j][nj]
+		})
+
+		path, err := e.showPath(origin, id, file)
+		if err != nil {
+			return err
+		}
+		err = e.write(path, sorted)
+		if err != nil {
+			return err
+		}
+		shows[filepath.Base(filepath.Dir(path))] = true
+	}
+
+	return e.prune(origin, func(show, name string) bool {
+		return name != file || shows[show]
+	})
+}
+
+// ExportAllNames exports the names of an origin in each language like
+// ExportNames, and removes the names files of languages not given.
+func (e *Exporter) ExportAllNames(origin string, names map[string]map[string]([]map[string]int)) error {
+	files := make(map[string]bool)
+	for lang, byLang := range names {
+		err := e.ExportNames(origin, lang, byLang)
+		if err != nil {
+			return err
+		}
+		files[namesFile(lang)] = true
+	}
+
+	return e.prune(origin, func(show, name string) bool {
+		return !strings.HasPrefix(name, "names-") || files[name]
+	})
+}
+
+// ExportAll retrieves and exports the mappings of the given shows
+func (e *Exporter) ExportAll(s Source, origin string, ids ...string) error {
+	for _, id := range ids {
+		all, err := s.All(origin, id)
+		if err != nil {
+			return err
+		}
+		err = e.ExportMappings(origin, id, all)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Exporter) showPath(origin, id, name string) (string, error) {
+	if id == "" || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid %v id %q", origin, id)
+	}
+	return filepath.Join(e.Dir, url.PathEscape(origin), url.PathEscape(id), name), nil
+}
+
+// prune removes the files of an origin's show directories that keep rejects,
+// then removes show directories left empty.
+func (e *Exporter) prune(origin string, keep func(show, name string) bool) error {
+	dir := filepath.Join(e.Dir, url.PathEscape(origin))
+	shows, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, show := range shows {
+		if !show.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, show.Name())
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		left := len(files)
+		for _, f := range files {
+			if keep(show.Name(), f.Name()) {
+				continue
+			}
+			err = os.Remove(filepath.Join(path, f.Name()))
+			if err != nil {
+				return err
+			}
+			left--
+		}
+		if left == 0 {
+			err = os.Remove(path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func namesFile(lang string) string {
+	return "names-" + url.PathEscape(lang) + ".json"
+}
+
+func (e *Exporter) write(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	existing, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func firstName(m map[string]int) string {
+	for name := range m {
+		return name
+	}
+	return ""
+}
+
+// mappingsByOrigin sorts mappings by their episode of an origin, then by their
+// JSON encoding.
+type mappingsByOrigin struct {
+	origin   string
+	mappings []Mapping
+	keys     []string
+}
+
+func (s mappingsByOrigin) Len() int { return len(s.mappings) }
+
+func (s mappingsByOrigin) Less(i, j int) bool {
+	if c := s.mappings[i][s.origin].Compare(s.mappings[j][s.origin]); c != 0 {
+		return c < 0
+	}
+	return s.keys[i] < s.keys[j]
+}
+
+func (s mappingsByOrigin) Swap(i, j int) {
+	s.mappings[i], s.mappings[j] = s.mappings[j], s.mappings[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
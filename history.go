@@ -0,0 +1,221 @@
+package xem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Revision of the mappings of a show, identified by the hash of its content
+type Revision struct {
+	Time time.Time `json:"time"`
+	Hash string    `json:"hash"`
+}
+
+// History records the mappings of shows over time in a directory. Mappings are
+// stored once per distinct content, and a revision is only added when the
+// mappings of a show change.
+type History struct {
+	Dir string
+
+	// RecordError, if set, is called when a result fetched through Record
+	// cannot be added to the history. The result is returned regardless.
+	RecordError func(origin, id string, err error)
+
+	mu sync.Mutex
+}
+
+// NewHistory creates a history stored in the given directory
+func NewHistory(dir string) (*History, error) {
+	for _, sub := range []string{"objects", "shows"} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &History{Dir: dir}, nil
+}
+
+// Record wraps a Source so that every successfully fetched result is added to
+// the history. Failures to add a result are reported to RecordError.
+func (h *History) Record(s Source) Source {
+	return &recordingSource{history: h, source: s}
+}
+
+type recordingSource struct {
+	history *History
+	source  Source
+}
+
+func (r *recordingSource) All(origin, id string) ([]Mapping, error) {
+	all, err := r.source.All(origin, id)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.history.Add(origin, id, all, time.Now())
+	if err != nil && r.history.RecordError != nil {
+		r.history.RecordError(origin, id, fmt.Errorf("unable to record history: %w", err))
+	}
+	return all, nil
+}
+
+// Add records the mappings of a show fetched at the given time. Revisions are
+// kept in time order, so results fetched earlier may be added later. If the
+// mappings are unchanged since the preceding revision, that revision is
+// returned instead.
+func (h *History) Add(origin, id string, all []Mapping, t time.Time) (Revision, error) {
+	data, hash, err := hashMappings(all)
+	if err != nil {
+		return Revision{}, err
+	}
+	rev := Revision{Time: t, Hash: hash}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	revs, err := h.revisions(origin, id)
+	if err != nil {
+		return Revision{}, err
+	}
+	i := sort.Search(len(revs), func(i int) bool { return revs[i].Time.After(t) })
+	if i > 0 && revs[i-1].Hash == rev.Hash {
+		return revs[i-1], nil
+	}
+
+	object := h.objectPath(rev.Hash)
+	if _, err := os.Stat(object); os.IsNotExist(err) {
+		err = writeFileAtomic(object, data)
+		if err != nil {
+			return Revision{}, err
+		}
+	}
+
+	if i < len(revs) && revs[i].Hash == rev.Hash {
+		// The following revision has the same content, seen earlier than known
+		revs[i].Time = t
+	} else {
+		revs = append(revs[:i], append([]Revision{rev}, revs[i:]...)...)
+	}
+	data, err = json.Marshal(revs)
+	if err != nil {
+		return Revision{}, err
+	}
+	err = os.MkdirAll(filepath.Dir(h.showPath(origin, id)), 0755)
+	if err != nil {
+		return Revision{}, err
+	}
+	err = writeFileAtomic(h.showPath(origin, id), data)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	return rev, nil
+}
+
+// hashMappings encodes mappings and returns the encoding and its hash. Map
+// keys are encoded in sorted order, so equal mappings have equal hashes.
+func hashMappings(all []Mapping) ([]byte, string, error) {
+	data, err := json.Marshal(all)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// Revisions lists the revisions of a show, oldest first
+func (h *History) Revisions(origin, id string) ([]Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.revisions(origin, id)
+}
+
+// AsOf retrieves the mappings of a show as they were at the given time
+func (h *History) AsOf(origin, id string, t time.Time) ([]Mapping, error) {
+	revs, err := h.Revisions(origin, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(revs) - 1; i >= 0; i-- {
+		if !revs[i].Time.After(t) {
+			return h.Load(revs[i].Hash)
+		}
+	}
+
+	return nil, fmt.Errorf("no revision of %v %v as of %v", origin, id, t)
+}
+
+// Load retrieves the mappings of a revision by hash
+func (h *History) Load(hash string) ([]Mapping, error) {
+	data, err := ioutil.ReadFile(h.objectPath(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Mapping
+	err = json.Unmarshal(data, &all)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode revision %v: %v", hash, err)
+	}
+
+	return all, nil
+}
+
+func (h *History) revisions(origin, id string) ([]Revision, error) {
+	data, err := ioutil.ReadFile(h.showPath(origin, id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revs []Revision
+	err = json.Unmarshal(data, &revs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode revisions of %v %v: %v", origin, id, err)
+	}
+
+	return revs, nil
+}
+
+func (h *History) objectPath(hash string) string {
+	return filepath.Join(h.Dir, "objects", hash+".json")
+}
+
+func (h *History) showPath(origin, id string) string {
+	return filepath.Join(h.Dir, "shows", url.PathEscape(origin), url.PathEscape(id)+".json")
+}
+
+// writeFileAtomic writes a file by renaming a temporary file into place, so
+// readers never see partial content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,130 @@
+package xem
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MappingEntry is a mapping as returned by the XEM API, including all parts
+// of multi-part episodes and any fields that are not recognized.
+type MappingEntry struct {
+	// Mapping holds the episode, or first part, of each origin
+	Mapping Mapping
+
+	// Parts holds every part of multi-part episodes by origin, starting with
+	// the part in Mapping. Keys such as "scene_2" hold the second part.
+	Parts map[string][]Episode
+
+	// Raw holds fields that are not recognized as episodes, unchanged
+	Raw map[string]json.RawMessage
+}
+
+// splitPartKey splits a multi-part key such as "scene_2" into its origin and
+// part number. Keys without a part suffix are the first part.
+func splitPartKey(key string) (origin string, part int) {
+	i := strings.LastIndex(key, "_")
+	if i <= 0 {
+		return key, 1
+	}
+	n, err := strconv.Atoi(key[i+1:])
+	if err != nil || n < 2 {
+		return key, 1
+	}
+	return key[:i], n
+}
+
+// decodeEpisode decodes an episode in the XEM API object format. Objects
+// without any of the episode fields are not episodes.
+func decodeEpisode(data json.RawMessage) (Episode, bool) {
+	var fields map[string]json.RawMessage
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, &fields) != nil {
+		return Episode{}, false
+	}
+	_, season := fields["season"]
+	_, episode := fields["episode"]
+	_, absolute := fields["absolute"]
+	if !season && !episode && !absolute {
+		return Episode{}, false
+	}
+
+	var ep episodeJSON
+	if json.Unmarshal(data, &ep) != nil {
+		return Episode{}, false
+	}
+	return Episode(ep), true
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *MappingEntry) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	type part struct {
+		n       int
+		episode Episode
+	}
+	parts := make(map[string][]part)
+	*e = MappingEntry{Mapping: make(Mapping)}
+	for key, value := range fields {
+		ep, ok := decodeEpisode(value)
+		if !ok {
+			if e.Raw == nil {
+				e.Raw = make(map[string]json.RawMessage)
+			}
+			e.Raw[key] = value
+			continue
+		}
+		origin, n := splitPartKey(key)
+		parts[origin] = append(parts[origin], part{n, ep})
+	}
+
+	for origin, list := range parts {
+		sort.Slice(list, func(i, j int) bool { return list[i].n < list[j].n })
+		e.Mapping[origin] = list[0].episode
+		if len(list) == 1 {
+			continue
+		}
+		if e.Parts == nil {
+			e.Parts = make(map[string][]Episode)
+		}
+		for _, p := range list {
+			e.Parts[origin] = append(e.Parts[origin], p.episode)
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, producing the XEM API format.
+func (e MappingEntry) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{})
+	for key, value := range e.Raw {
+		fields[key] = value
+	}
+	for origin, ep := range e.Mapping {
+		fields[origin] = ep
+	}
+	for origin, parts := range e.Parts {
+		for i, ep := range parts {
+			if i > 0 {
+				fields[origin+"_"+strconv.Itoa(i+1)] = ep
+			}
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Additional parts of multi-part
+// episodes and unrecognized fields are skipped; use MappingEntry to keep them.
+func (m *Mapping) UnmarshalJSON(data []byte) error {
+	var e MappingEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	*m = e.Mapping
+	return nil
+}
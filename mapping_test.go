@@ -0,0 +1,36 @@
+package xem
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMappingEntryUnmarshal(t *testing.T) {
+	data := `{
+		"scene": {"season": 1, "episode": 1, "absolute": 1},
+		"scene_2": {"season": 1, "episode": 2, "absolute": 2},
+		"tvdb": {"season": 1, "episode": 1, "absolute": 1},
+		"meta": {"source": "x"},
+		"rating": 5
+	}`
+
+	var e MappingEntry
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.Mapping) != 2 {
+		t.Errorf("expected scene and tvdb origins, got %v", e.Mapping)
+	}
+	if got := e.Mapping[Scene]; got != (Episode{Season: 1, Episode: 1, Absolute: 1}) {
+		t.Errorf("scene episode is %v", got)
+	}
+	if parts := e.Parts[Scene]; len(parts) != 2 || parts[1] != (Episode{Season: 1, Episode: 2, Absolute: 2}) {
+		t.Errorf("scene parts are %v", parts)
+	}
+	for _, key := range []string{"meta", "rating"} {
+		if _, ok := e.Raw[key]; !ok {
+			t.Errorf("%v missing from raw fields %v", key, e.Raw)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package xem
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NamedSource is a Source identified by name in merge results
+type NamedSource struct {
+	Name   string
+	Source Source
+}
+
+// MappingSet is a list of mappings retrieved from a named source
+type MappingSet struct {
+	Source   string
+	Mappings []Mapping
+}
+
+// OriginPair is an unordered pair of origins
+type OriginPair [2]string
+
+// Pair returns the OriginPair of two origins, in either order
+func Pair(a, b string) OriginPair {
+	if b < a {
+		a, b = b, a
+	}
+	return OriginPair{a, b}
+}
+
+// Merger combines mappings from multiple sources. When sources disagree on
+// the episode an origin maps to, the source with the highest precedence for
+// that origin pair wins and the disagreement is reported as a Conflict.
+type Merger struct {
+	Sources []NamedSource
+
+	// Precedence lists source names from highest to lowest precedence, by
+	// origin pair. Sources not listed rank below listed ones.
+	Precedence map[OriginPair][]string
+
+	// DefaultPrecedence is used for origin pairs without a Precedence entry
+	DefaultPrecedence []string
+}
+
+// MergedMapping is a merged Mapping and the name of the source chosen for each
+// origin it maps to.
+type MergedMapping struct {
+	Mapping Mapping
+	Sources map[string]string
+}
+
+// Conflict is a disagreement between sources on the episode of an origin that
+// an episode of the queried origin maps to.
+type Conflict struct {
+	Origin     string
+	Episode    Episode
+	Target     string
+	Candidates map[string]Episode
+
+	// Winner is the name of the chosen source, or empty if no source has
+	// precedence, in which case the target origin is left out of the mapping.
+	Winner string
+}
+
+// MergeResult is the result of merging mappings.
+type MergeResult struct {
+	Merged    []MergedMapping
+	Conflicts []Conflict
+
+	// Errors of sources that could not be retrieved, by source name
+	Errors map[string]error
+}
+
+// Mappings returns the merged mappings without their sources.
+func (r *MergeResult) Mappings() []Mapping {
+	all := make([]Mapping, len(r.Merged))
+	for i, m := range r.Merged {
+		all[i] = m.Mapping
+	}
+	return all
+}
+
+// All retrieves and merges the mappings of all sources. It implements Source.
+func (m *Merger) All(origin, id string) ([]Mapping, error) {
+	r, err := m.Merge(origin, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.Mappings(), nil
+}
+
+// Merge retrieves and merges the mappings of all sources for the given origin
+// and ID. Sources that fail are recorded in the result; an error is only
+// returned if every source fails.
+func (m *Merger) Merge(origin, id string) (*MergeResult, error) {
+	var sets []MappingSet
+	errs := make(map[string]error)
+	for _, s := range m.Sources {
+		all, err := s.Source.All(origin, id)
+		if err != nil {
+			errs[s.Name] = err
+			continue
+		}
+		sets = append(sets, MappingSet{Source: s.Name, Mappings: all})
+	}
+	if len(sets) == 0 && len(m.Sources) > 0 {
+		return nil, fmt.Errorf("all sources failed: %v", errs[m.Sources[0].Name])
+	}
+
+	r := m.MergeSets(origin, sets)
+	r.Errors = errs
+	return r, nil
+}
+
+// MergeSets merges mappings retrieved for the given origin. Mappings are
+// matched on their episode of that origin; mappings without it are ignored.
+// Episodes are compared with Episode.Matches, so a source that leaves out the
+// absolute number does not disagree with one that includes it.
+func (m *Merger) MergeSets(origin string, sets []MappingSet) *MergeResult {
+	type candidate struct {
+		source  string
+		episode Episode
+	}
+	type group struct {
+		episode Episode
+		// Candidates by target origin
+		candidates map[string][]candidate
+	}
+	var groups []*group
+	bySeason := make(map[[2]int]*group)
+	byAbsolute := make(map[int]*group)
+	find := func(ep Episode) *group {
+		if g, ok := bySeason[[2]int{ep.Season, ep.Episode}]; ok && ep.HasSeason() {
+			return g
+		}
+		if g, ok := byAbsolute[ep.Absolute]; ok && ep.HasAbsolute() && g.episode.Matches(ep) {
+			return g
+		}
+		return nil
+	}
+	// index records the numbers of a group. A group that gained a number may
+	// now match groups found by that number alone, which are folded into it.
+	index := func(g *group) {
+		for {
+			var others []*group
+			if g.episode.HasSeason() {
+				k := [2]int{g.episode.Season, g.episode.Episode}
+				if o := bySeason[k]; o != nil && o != g && o.episode.Matches(g.episode) {
+					others = append(others, o)
+				}
+				bySeason[k] = g
+			}
+			if g.episode.HasAbsolute() {
+				if o := byAbsolute[g.episode.Absolute]; o != nil && o != g && o.episode.Matches(g.episode) {
+					others = append(others, o)
+				}
+				byAbsolute[g.episode.Absolute] = g
+			}
+			if len(others) == 0 {
+				return
+			}
+
+			for _, o := range others {
+				g.episode = fillEpisode(g.episode, o.episode)
+				for target, cands := range o.candidates {
+					g.candidates[target] = append(g.candidates[target], cands...)
+				}
+				for i := range groups {
+					if groups[i] == o {
+						groups = append(groups[:i], groups[i+1:]...)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for _, set := range sets {
+		seen := make(map[*group]bool)
+		for _, mapping := range set.Mappings {
+			ep, ok := mapping[origin]
+			if !ok || ep.IsZero() {
+				continue
+			}
+			g := find(ep)
+			if g == nil {
+				g = &group{episode: ep, candidates: make(map[string][]candidate)}
+				groups = append(groups, g)
+			} else {
+				g.episode = fillEpisode(g.episode, ep)
+			}
+			index(g)
+			if seen[g] {
+				continue
+			}
+			seen[g] = true
+
+			for target, targetEp := range mapping {
+				if target == origin {
+					continue
+				}
+				g.candidates[target] = append(g.candidates[target], candidate{set.Source, targetEp})
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].episode.Less(groups[j].episode) })
+
+	r := &MergeResult{}
+	for _, g := range groups {
+		merged := MergedMapping{
+			Mapping: Mapping{origin: g.episode},
+			Sources: make(map[string]string),
+		}
+
+		targets := make([]string, 0, len(g.candidates))
+		for target := range g.candidates {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			cands := g.candidates[target]
+			precedence := m.precedence(origin, target)
+
+			best := cands[0]
+			tied := false
+			agree := true
+			for _, c := range cands[1:] {
+				if !c.episode.Matches(best.episode) {
+					agree = false
+				}
+				switch rc, rb := precedence(c.source), precedence(best.source); {
+				case rc < rb:
+					best, tied = c, false
+				case rc == rb && !c.episode.Matches(best.episode):
+					tied = true
+				}
+			}
+
+			if !agree {
+				conflict := Conflict{
+					Origin:     origin,
+					Episode:    g.episode,
+					Target:     target,
+					Candidates: make(map[string]Episode),
+				}
+				for _, c := range cands {
+					conflict.Candidates[c.source] = c.episode
+				}
+				if !tied {
+					conflict.Winner = best.source
+				}
+				r.Conflicts = append(r.Conflicts, conflict)
+				if tied {
+					continue
+				}
+			}
+
+			// Complete the chosen episode with numbers from agreeing sources
+			ep := best.episode
+			for _, c := range cands {
+				ep = fillEpisode(ep, c.episode)
+			}
+			merged.Mapping[target] = ep
+			merged.Sources[target] = best.source
+		}
+
+		r.Merged = append(r.Merged, merged)
+	}
+
+	return r
+}
+
+// fillEpisode returns e with the numbers it is missing taken from o, if both
+// refer to the same episode.
+func fillEpisode(e, o Episode) Episode {
+	if !e.Matches(o) {
+		return e
+	}
+	if !e.HasSeason() && o.HasSeason() {
+		e.Season, e.Episode = o.Season, o.Episode
+	}
+	if !e.HasAbsolute() && o.HasAbsolute() {
+		e.Absolute = o.Absolute
+	}
+	return e
+}
+
+// precedence returns a function ranking source names for an origin pair,
+// where lower ranks take precedence.
+func (m *Merger) precedence(a, b string) func(source string) int {
+	order, ok := m.Precedence[Pair(a, b)]
+	if !ok {
+		order = m.DefaultPrecedence
+	}
+	return func(source string) int {
+		for i, name := range order {
+			if name == source {
+				return i
+			}
+		}
+		return len(order)
+	}
+}
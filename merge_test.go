@@ -0,0 +1,97 @@
+package xem
+
+import (
+	"strings"
+	"testing"
+)
+
+type staticSource []Mapping
+
+func (s staticSource) All(origin, id string) ([]Mapping, error) {
+	return s, nil
+}
+
+const mergeAnimeList = `<anime-list>
+  <anime anidbid="1" tvdbid="100" defaulttvdbseason="1" episodeoffset="">
+    <name>Test</name>
+  </anime>
+</anime-list>`
+
+func TestMergeXEMAndAnimeList(t *testing.T) {
+	xem := staticSource{
+		{
+			AniDB: {Season: 1, Episode: 1, Absolute: 1},
+			TVDB:  {Season: 1, Episode: 1, Absolute: 1},
+			Scene: {Season: 1, Episode: 1, Absolute: 1},
+		},
+		{
+			AniDB: {Season: 1, Episode: 2, Absolute: 2},
+			TVDB:  {Season: 1, Episode: 2, Absolute: 2},
+			Scene: {Season: 1, Episode: 2, Absolute: 2},
+		},
+	}
+	list, err := ParseAnimeList(strings.NewReader(mergeAnimeList), func(int) int { return 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Merger{
+		Sources:           []NamedSource{{"xem", xem}, {"anime-lists", list}},
+		DefaultPrecedence: []string{"xem", "anime-lists"},
+	}
+	for _, q := range []struct{ origin, id string }{{AniDB, "1"}, {TVDB, "100"}} {
+		r, err := m.Merge(q.origin, q.id)
+		if err != nil {
+			t.Fatalf("%v: %v", q.origin, err)
+		}
+		if len(r.Conflicts) != 0 {
+			t.Errorf("%v: unexpected conflicts: %+v", q.origin, r.Conflicts)
+		}
+		if len(r.Merged) != 2 {
+			t.Fatalf("%v: expected 2 merged mappings, got %+v", q.origin, r.Merged)
+		}
+		for i, merged := range r.Merged {
+			want := xem[i]
+			for origin, ep := range want {
+				if merged.Mapping[origin] != ep {
+					t.Errorf("%v: mapping %d: %v is %v, want %v", q.origin, i, origin, merged.Mapping[origin], ep)
+				}
+			}
+		}
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	m := &Merger{DefaultPrecedence: []string{"a"}}
+	r := m.MergeSets(TVDB, []MappingSet{
+		{"a", []Mapping{{TVDB: {Season: 1, Episode: 1}, Scene: {Season: 1, Episode: 1}}}},
+		{"b", []Mapping{{TVDB: {Season: 1, Episode: 1, Absolute: 1}, Scene: {Season: 1, Episode: 2}}}},
+	})
+	if len(r.Merged) != 1 {
+		t.Fatalf("expected 1 merged mapping, got %+v", r.Merged)
+	}
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Winner != "a" {
+		t.Fatalf("expected 1 conflict won by a, got %+v", r.Conflicts)
+	}
+	if got := r.Merged[0].Mapping[Scene]; got != (Episode{Season: 1, Episode: 1}) {
+		t.Errorf("scene episode is %v, want S01E01", got)
+	}
+}
+
+func TestMergePartialEpisodes(t *testing.T) {
+	m := &Merger{}
+	r := m.MergeSets(TVDB, []MappingSet{
+		{"a", []Mapping{{TVDB: {Season: 1, Episode: 5}, Scene: {Season: 1, Episode: 5}}}},
+		{"b", []Mapping{{TVDB: {Absolute: 5}, Scene: {Season: 1, Episode: 5}}}},
+		{"c", []Mapping{{TVDB: {Season: 1, Episode: 5, Absolute: 5}, Scene: {Season: 1, Episode: 5}}}},
+	})
+	if len(r.Merged) != 1 {
+		t.Fatalf("expected 1 merged mapping, got %+v", r.Merged)
+	}
+	if len(r.Conflicts) != 0 {
+		t.Errorf("unexpected conflicts: %+v", r.Conflicts)
+	}
+	if got, want := r.Merged[0].Mapping[TVDB], (Episode{Season: 1, Episode: 5, Absolute: 5}); got != want {
+		t.Errorf("tvdb episode is %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,232 @@
+package xem
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TitleAlias is the title type of names retrieved from XEM
+const TitleAlias = "alias"
+
+// Fuzzy name matching limits
+const (
+	minNameSimilarity = 0.5
+	maxFuzzyMatches   = 20
+)
+
+// trivialWords are too common in titles to indicate a match by themselves
+var trivialWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "to": true,
+	"in": true, "on": true, "no": true, "wa": true, "ga": true, "wo": true,
+	"ni": true, "de": true, "season": true, "part": true,
+	"movie": true, "special": true, "specials": true, "ova": true, "tv": true,
+}
+
+// NameEntry is a show name known for an ID of an origin.
+type NameEntry struct {
+	Origin   string
+	ID       string
+	Season   int
+	Name     string
+	Type     string
+	Language string
+}
+
+// NameIndex looks up shows by name, combining XEM aliases and AniDB titles.
+// Names are matched case-insensitively, ignoring punctuation and spacing.
+type NameIndex struct {
+	names map[string][]NameEntry
+
+	// Normalized names by significant word, for fuzzy matching
+	words map[string][]string
+}
+
+// NewNameIndex creates an empty name index
+func NewNameIndex() *NameIndex {
+	return &NameIndex{
+		names: make(map[string][]NameEntry),
+		words: make(map[string][]string),
+	}
+}
+
+// Add adds a single name to the index
+func (x *NameIndex) Add(e NameEntry) {
+	key := normalizeName(e.Name)
+	if key == "" {
+		return
+	}
+	for _, existing := range x.names[key] {
+		if existing == e {
+			return
+		}
+	}
+	if _, ok := x.names[key]; !ok {
+		for _, w := range significantWords(key) {
+			x.words[w] = append(x.words[w], key)
+		}
+	}
+	x.names[key] = append(x.names[key], e)
+}
+
+// AddNames adds the result of Client.Names for the given origin and language
+func (x *NameIndex) AddNames(origin, lang string, names map[string]([]map[string]int)) {
+	for id, list := range names {
+		for _, m := range list {
+			for name, season := range m {
+				x.Add(NameEntry{
+					Origin:   origin,
+					ID:       id,
+					Season:   season,
+					Name:     name,
+					Type:     TitleAlias,
+					Language: lang,
+				})
+			}
+		}
+	}
+}
+
+// AddTitles adds AniDB titles to the index. Titles apply to all seasons.
+func (x *NameIndex) AddTitles(titles []AnimeTitle) {
+	for _, t := range titles {
+		x.Add(NameEntry{
+			Origin:   AniDB,
+			ID:       strconv.Itoa(t.AniDBID),
+			Season:   -1,
+			Name:     t.Title,
+			Type:     t.Type,
+			Language: t.Language,
+		})
+	}
+}
+
+// Lookup returns all entries matching the given name
+func (x *NameIndex) Lookup(name string) []NameEntry {
+	return x.names[normalizeName(name)]
+}
+
+// NameMatch is an entry matching a name, with the confidence of the match
+type NameMatch struct {
+	NameEntry
+	Confidence Confidence
+}
+
+// Resolve returns the entries matching the given name, best first. If no name
+// matches after normalization, names sharing at least half of their
+// significant words with it are matched with lower confidence, up to a limit.
+func (x *NameIndex) Resolve(name string) []NameMatch {
+	key := normalizeName(name)
+	var matches []NameMatch
+	for _, e := range x.names[key] {
+		c := Confidence{Score: 1}
+		if e.Name == name {
+			c.Reasons = append(c.Reasons, ReasonExactName)
+		} else {
+			c.Score *= normalizedNamePenalty
+			c.Reasons = append(c.Reasons, ReasonNormalizedName)
+		}
+		matches = append(matches, NameMatch{e, c})
+	}
+
+	fuzzy := len(matches) == 0
+	if fuzzy {
+		candidates := make(map[string]bool)
+		for _, w := range significantWords(key) {
+			for _, other := range x.words[w] {
+				candidates[other] = true
+			}
+		}
+		for other := range candidates {
+			similarity := nameSimilarity(key, other)
+			if similarity < minNameSimilarity {
+				continue
+			}
+			for _, e := range x.names[other] {
+				c := Confidence{
+					Score:   similarity * fuzzyNamePenalty,
+					Reasons: []string{ReasonFuzzyName},
+				}
+				matches = append(matches, NameMatch{e, c})
+			}
+		}
+	}
+
+	// The same show is usually known to several origins, so only different
+	// IDs of one origin make the name ambiguous
+	ids := make(map[string]map[string]bool)
+	ambiguous := false
+	for _, m := range matches {
+		if ids[m.Origin] == nil {
+			ids[m.Origin] = make(map[string]bool)
+		}
+		ids[m.Origin][m.ID] = true
+		ambiguous = ambiguous || len(ids[m.Origin]) > 1
+	}
+	for i := range matches {
+		c := &matches[i].Confidence
+		switch matches[i].Type {
+		case TitleSynonym, TitleShort:
+			c.Score *= alternateTitlePenalty
+			c.Reasons = append(c.Reasons, ReasonAlternateTitle)
+		}
+		if ambiguous {
+			c.Score *= multipleShowsPenalty
+			c.Reasons = append(c.Reasons, ReasonMultipleShows)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Confidence.Score != matches[j].Confidence.Score {
+			return matches[i].Confidence.Score > matches[j].Confidence.Score
+		}
+		return matches[i].Name < matches[j].Name
+	})
+	if fuzzy && len(matches) > maxFuzzyMatches {
+		matches = matches[:maxFuzzyMatches]
+	}
+	return matches
+}
+
+// significantWords returns the words of a normalized name that are not
+// trivial
+func significantWords(name string) []string {
+	var words []string
+	for _, w := range strings.Fields(name) {
+		if !trivialWords[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// nameSimilarity returns the proportion of significant words shared by two
+// normalized names, from 0 to 1.
+func nameSimilarity(a, b string) float64 {
+	words := make(map[string]int)
+	for _, w := range significantWords(a) {
+		words[w] |= 1
+	}
+	for _, w := range significantWords(b) {
+		words[w] |= 2
+	}
+	shared := 0
+	for _, v := range words {
+		if v == 3 {
+			shared++
+		}
+	}
+	if len(words) == 0 {
+		return 0
+	}
+	return float64(shared) / float64(len(words))
+}
+
+// normalizeName lowercases a name and reduces it to space-separated words.
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,107 @@
+package xem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// EpisodeNFO is an episode NFO document as read by Kodi and Jellyfin. It
+// numbers the episode by TVDB, so media servers index it correctly whatever
+// numbering its file name uses.
+type EpisodeNFO struct {
+	XMLName   xml.Name `xml:"episodedetails"`
+	Title     string   `xml:"title,omitempty"`
+	ShowTitle string   `xml:"showtitle,omitempty"`
+	Season    int      `xml:"season"`
+	Episode   int      `xml:"episode"`
+	Absolute  int      `xml:"absolute_number,omitempty"`
+
+	// Alternates holds the numbering of other origins. It is informational
+	// and ignored by media servers.
+	Alternates []AlternateNumbering `xml:"alternatenumbering"`
+}
+
+// AlternateNumbering is the numbering of an episode in another origin
+type AlternateNumbering struct {
+	Origin   string `xml:"origin,attr"`
+	Season   int    `xml:"season,attr"`
+	Episode  int    `xml:"episode,attr"`
+	Absolute int    `xml:"absolute,attr,omitempty"`
+}
+
+// NewEpisodeNFO creates an episode NFO from a mapping, which must include a
+// TVDB season and episode.
+func NewEpisodeNFO(m Mapping) (*EpisodeNFO, error) {
+	tvdb, ok := m[TVDB]
+	if !ok || !tvdb.HasSeason() {
+		return nil, fmt.Errorf("mapping has no TVDB episode: %v", m)
+	}
+
+	n := &EpisodeNFO{
+		Season:   tvdb.Season,
+		Episode:  tvdb.Episode,
+		Absolute: tvdb.Absolute,
+	}
+
+	origins := make([]string, 0, len(m))
+	for origin := range m {
+		if origin != TVDB {
+			origins = append(origins, origin)
+		}
+	}
+	sort.Strings(origins)
+	for _, origin := range origins {
+		ep := m[origin]
+		if ep.IsZero() {
+			continue
+		}
+		n.Alternates = append(n.Alternates, AlternateNumbering{
+			Origin:   origin,
+			Season:   ep.Season,
+			Episode:  ep.Episode,
+			Absolute: ep.Absolute,
+		})
+		// Fall back to the scene absolute number when TVDB has none
+		if n.Absolute == 0 && ep.HasAbsolute() && origin == Scene {
+			n.Absolute = ep.Absolute
+		}
+	}
+
+	return n, nil
+}
+
+// WriteTo writes the NFO document, including the XML header.
+func (n *EpisodeNFO) WriteTo(w io.Writer) (int64, error) {
+	data, err := xml.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	data = append([]byte(xml.Header), data...)
+	data = append(data, '\n')
+
+	written, err := w.Write(data)
+	return int64(written), err
+}
+
+// WriteEpisodeNFO writes the NFO of a mapping to a file, usually named after
+// the video file with an ".nfo" extension.
+func WriteEpisodeNFO(path string, m Mapping) error {
+	n, err := NewEpisodeNFO(m)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = n.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,100 @@
+package xem
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Origins provided by XEM
+var xemOrigins = []string{AniDB, Scene, TVDB}
+
+// Origin is a custom origin, such as an internal catalog numbering, served by
+// its own providers rather than XEM.
+type Origin struct {
+	Name string
+
+	// Source provides mappings between the origin and at least one other
+	// origin. It is queried both by the origin's own IDs and by the IDs of
+	// other origins, and should return no mappings or an error wrapping
+	// ErrNotFound for unknown shows.
+	Source Source
+
+	// Names optionally provides the names of the origin's shows, in the same
+	// format as Client.Names.
+	Names func(lang string) (map[string]([]map[string]int), error)
+}
+
+// RegisterOrigin registers a custom origin with the client. Mappings for the
+// origin are retrieved from its Source and joined with XEM mappings.
+func (c *Client) RegisterOrigin(o Origin) error {
+	if o.Name == "" || o.Source == nil {
+		return fmt.Errorf("origin requires a name and source")
+	}
+	for _, name := range xemOrigins {
+		if o.Name == name {
+			return fmt.Errorf("origin %v is provided by XEM", o.Name)
+		}
+	}
+	if _, ok := c.origins[o.Name]; ok {
+		return fmt.Errorf("origin %v is already registered", o.Name)
+	}
+
+	if c.origins == nil {
+		c.origins = make(map[string]Origin)
+	}
+	c.origins[o.Name] = o
+	return nil
+}
+
+// Origins returns the names of all XEM and registered custom origins
+func (c *Client) Origins() []string {
+	return append(append([]string{}, xemOrigins...), c.customOrigins()...)
+}
+
+func (c *Client) customOrigins() []string {
+	var custom []string
+	for name := range c.origins {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	return custom
+}
+
+// joinOrigins adds the episodes of registered origins to XEM mappings. Shows
+// unknown to a custom origin are left as they are.
+func (c *Client) joinOrigins(origin, id string, all []Mapping) error {
+	for _, name := range c.customOrigins() {
+		extra, err := c.origins[name].Source.All(origin, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("origin %v: %w", name, err)
+		}
+		joinMappings(all, extra, name)
+	}
+	return nil
+}
+
+// joinMappings sets the episode of the given origin on every mapping that
+// shares an episode of another origin with one of the extra mappings.
+func joinMappings(all, extra []Mapping, origin string) {
+	for _, e := range extra {
+		ep, ok := e[origin]
+		if !ok {
+			continue
+		}
+		for _, m := range all {
+			if _, ok := m[origin]; ok {
+				continue
+			}
+			for shared, sharedEp := range e {
+				if got, ok := m[shared]; ok && shared != origin && got.Matches(sharedEp) {
+					m[origin] = ep
+					break
+				}
+			}
+		}
+	}
+}
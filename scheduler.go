@@ -0,0 +1,289 @@
+package xem
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default refresh intervals
+const (
+	defaultMinInterval = 6 * time.Hour
+	defaultMaxInterval = 30 * 24 * time.Hour
+)
+
+// ShowSchedule is the refresh schedule of a show
+type ShowSchedule struct {
+	Origin      string        `json:"origin"`
+	ID          string        `json:"id"`
+	Hash        string        `json:"hash,omitempty"`
+	Interval    time.Duration `json:"interval"`
+	Checked     time.Time     `json:"checked"`
+	Changed     time.Time     `json:"changed"`
+	Next        time.Time     `json:"next"`
+	Changes     int           `json:"changes"`
+	Refreshes   int           `json:"refreshes"`
+	LastFailure string        `json:"lastFailure,omitempty"`
+}
+
+// Scheduler decides when to refresh the mappings of shows, based on how often
+// they change. The interval of a show is halved whenever its mappings change
+// and doubled whenever they do not, within MinInterval and MaxInterval, so
+// finished series are polled less and less often.
+type Scheduler struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// Path of the file state is persisted to, or empty to keep it in memory
+	Path string
+
+	mu    sync.Mutex
+	shows map[[2]string]*ShowSchedule
+}
+
+// NewScheduler creates a scheduler, loading its state from path if it exists
+func NewScheduler(path string) (*Scheduler, error) {
+	s := &Scheduler{
+		MinInterval: defaultMinInterval,
+		MaxInterval: defaultMaxInterval,
+		Path:        path,
+		shows:       make(map[[2]string]*ShowSchedule),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var shows []*ShowSchedule
+	err = json.Unmarshal(data, &shows)
+	if err != nil {
+		return nil, err
+	}
+	for _, show := range shows {
+		s.shows[[2]string{show.Origin, show.ID}] = show
+	}
+
+	return s, nil
+}
+
+// Track adds a show to the schedule, due immediately. Shows already tracked
+// are unchanged.
+func (s *Scheduler) Track(origin, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{origin, id}
+	if _, ok := s.shows[key]; !ok {
+		s.shows[key] = &ShowSchedule{Origin: origin, ID: id, Interval: s.MinInterval}
+	}
+}
+
+// Untrack removes a show from the schedule
+func (s *Scheduler) Untrack(origin, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.shows, [2]string{origin, id})
+}
+
+// Schedules lists the schedules of all shows, soonest first
+func (s *Scheduler) Schedules() []ShowSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(func(*ShowSchedule) bool { return true })
+}
+
+// Due lists the schedules of shows due for a refresh at the given time
+func (s *Scheduler) Due(now time.Time) []ShowSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(func(show *ShowSchedule) bool { return !show.Next.After(now) })
+}
+
+func (s *Scheduler) list(match func(*ShowSchedule) bool) []ShowSchedule {
+	var shows []ShowSchedule
+	for _, show := range s.shows {
+		if match(show) {
+			shows = append(shows, *show)
+		}
+	}
+	sort.Slice(shows, func(i, j int) bool {
+		if !shows[i].Next.Equal(shows[j].Next) {
+			return shows[i].Next.Before(shows[j].Next)
+		}
+		return shows[i].Origin+"/"+shows[i].ID < shows[j].Origin+"/"+shows[j].ID
+	})
+	return shows
+}
+
+// Update records the mappings of a show refreshed at the given time,
+// schedules its next refresh and saves the state
+func (s *Scheduler) Update(origin, id string, all []Mapping, now time.Time) (ShowSchedule, error) {
+	hash, err := scheduleHash(all)
+	if err != nil {
+		return ShowSchedule{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.update(origin, id, hash, now)
+	return result, s.save()
+}
+
+// Fail records a failed refresh of a show, which is retried after
+// MinInterval without changing its interval, and saves the state
+func (s *Scheduler) Fail(origin, id string, failure error, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fail(origin, id, failure, now)
+	return s.save()
+}
+
+// Refresh retrieves the mappings of all shows due at the given time, updates
+// their schedules and saves the state once. Shows unknown to the source are
+// treated as having no mappings. The first failure is returned after all
+// shows are refreshed.
+//
+// Cached results would make changing shows look unchanged, so if src is a
+// Cache the entries of each show are invalidated before it is retrieved. Other
+// sources must not return cached results, such as those of a wrapped Cache.
+func (s *Scheduler) Refresh(src Source, now time.Time) error {
+	cache, _ := src.(*Cache)
+	var first error
+	for _, show := range s.Due(now) {
+		var all []Mapping
+		var err error
+		if cache != nil {
+			err = cache.Invalidate(show.Origin, show.ID)
+		}
+		if err == nil {
+			all, err = src.All(show.Origin, show.ID)
+		}
+		if errors.Is(err, ErrNotFound) {
+			all, err = nil, nil
+		}
+		var hash string
+		if err == nil {
+			hash, err = scheduleHash(all)
+		}
+
+		s.mu.Lock()
+		if err != nil {
+			s.fail(show.Origin, show.ID, err, now)
+		} else {
+			s.update(show.Origin, show.ID, hash, now)
+		}
+		s.mu.Unlock()
+
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	err := s.Save()
+	if first == nil {
+		first = err
+	}
+	return first
+}
+
+// Save persists the state of the scheduler to Path
+func (s *Scheduler) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save()
+}
+
+// save persists the state while the caller holds s.mu, so that concurrent
+// saves are written in order.
+func (s *Scheduler) save() error {
+	if s.Path == "" {
+		return nil
+	}
+
+	shows := s.list(func(*ShowSchedule) bool { return true })
+	data, err := json.MarshalIndent(shows, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, data)
+}
+
+// scheduleHash returns the hash used to detect changes of mappings. No
+// mappings and empty mappings hash the same.
+func scheduleHash(all []Mapping) (string, error) {
+	if len(all) == 0 {
+		all = nil
+	}
+	_, hash, err := hashMappings(all)
+	return hash, err
+}
+
+// update records a refresh without saving. The caller must hold s.mu.
+func (s *Scheduler) update(origin, id, hash string, now time.Time) ShowSchedule {
+	show := s.track(origin, id)
+	switch {
+	case show.Hash == "":
+		// First refresh; nothing to compare with yet
+	case show.Hash != hash:
+		show.Interval /= 2
+		show.Changed = now
+		show.Changes++
+	default:
+		show.Interval *= 2
+	}
+	show.Interval = s.clamp(show.Interval)
+	show.Hash = hash
+	show.Checked = now
+	show.Next = now.Add(show.Interval)
+	show.Refreshes++
+	show.LastFailure = ""
+	return *show
+}
+
+// fail records a failed refresh without saving. The caller must hold s.mu.
+func (s *Scheduler) fail(origin, id string, failure error, now time.Time) {
+	show := s.track(origin, id)
+	show.Checked = now
+	show.Next = now.Add(s.MinInterval)
+	show.LastFailure = failure.Error()
+}
+
+// track returns the schedule of a show, adding it if needed. The caller must
+// hold s.mu.
+func (s *Scheduler) track(origin, id string) *ShowSchedule {
+	key := [2]string{origin, id}
+	show, ok := s.shows[key]
+	if !ok {
+		show = &ShowSchedule{Origin: origin, ID: id, Interval: s.MinInterval}
+		s.shows[key] = show
+	}
+	return show
+}
+
+func (s *Scheduler) clamp(d time.Duration) time.Duration {
+	switch {
+	case d < s.MinInterval:
+		return s.MinInterval
+	case d > s.MaxInterval:
+		return s.MaxInterval
+	default:
+		return d
+	}
+}
@@ -0,0 +1,171 @@
+package xem
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Explanation describes how an episode was translated between origins
+type Explanation struct {
+	From    string
+	To      string
+	Episode Episode
+
+	// Result is the translated episode, valid only if Found is set
+	Result Episode
+	Found  bool
+
+	// Steps are the mappings used, one per origin traversed
+	Steps []Step
+
+	// Ambiguities describe steps where mappings disagreed, in which case the
+	// first matching mapping was used. Only steps leading to the result are
+	// included.
+	Ambiguities []string
+
+	Confidence Confidence
+}
+
+// Step is a single translation from one origin to another
+type Step struct {
+	From    string
+	To      string
+	Episode Episode
+	Result  Episode
+	Mapping Mapping
+
+	// Source is the name of the source that provided the mapping, if known
+	Source string
+}
+
+// translationRow is a mapping and the names of the sources of its origins.
+type translationRow struct {
+	mapping Mapping
+	source  func(origin string) string
+}
+
+// Translate translates an episode from one origin to another. If no mapping
+// contains both origins, the episode is translated through other origins. Use
+// Explain to also score the confidence of the result.
+func Translate(all []Mapping, from string, ep Episode, to string) (Episode, bool) {
+	x := Explain([]MappingSet{{Mappings: all}}, from, ep, to)
+	return x.Result, x.Found
+}
+
+// Explain translates an episode like Translate, using mappings from named
+// sources, and explains how the result was reached.
+func Explain(sets []MappingSet, from string, ep Episode, to string) *Explanation {
+	var rows []translationRow
+	for _, set := range sets {
+		name := set.Source
+		for _, m := range set.Mappings {
+			rows = append(rows, translationRow{m, func(string) string { return name }})
+		}
+	}
+	return explain(rows, from, ep, to)
+}
+
+// Explain translates an episode using the merged mappings, attributing each
+// step to the source chosen for its target origin.
+func (r *MergeResult) Explain(from string, ep Episode, to string) *Explanation {
+	rows := make([]translationRow, len(r.Merged))
+	for i, m := range r.Merged {
+		sources := m.Sources
+		rows[i] = translationRow{m.Mapping, func(origin string) string { return sources[origin] }}
+	}
+	return explain(rows, from, ep, to)
+}
+
+// explain searches breadth first through origins, so that the translation
+// with the fewest steps is found.
+func explain(rows []translationRow, from string, ep Episode, to string) *Explanation {
+	x := &Explanation{From: from, To: to, Episode: ep}
+	defer func() { x.Confidence = translationConfidence(x) }()
+	if from == to {
+		x.Result, x.Found = ep, true
+		return x
+	}
+
+	type state struct {
+		origin      string
+		episode     Episode
+		steps       []Step
+		ambiguities []string
+	}
+	visited := map[string]bool{from: true}
+	queue := []state{{origin: from, episode: ep}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		// Steps to each origin reachable from the current episode
+		next := make(map[string]Step)
+		ambiguities := make(map[string][]string)
+		var targets []string
+		for _, row := range rows {
+			got, ok := row.mapping[cur.origin]
+			if !ok || !got.Matches(cur.episode) {
+				continue
+			}
+			for origin, result := range row.mapping {
+				if visited[origin] || result.IsZero() {
+					continue
+				}
+				step, seen := next[origin]
+				if !seen {
+					next[origin] = Step{
+						From:    cur.origin,
+						To:      origin,
+						Episode: cur.episode,
+						Result:  result,
+						Mapping: row.mapping,
+						Source:  row.source(origin),
+					}
+					targets = append(targets, origin)
+				} else if !step.Result.Matches(result) {
+					ambiguities[origin] = append(ambiguities[origin], fmt.Sprintf(
+						"%v %v maps to both %v and %v in %v",
+						cur.origin, cur.episode, step.Result, result, origin))
+				}
+			}
+		}
+		sort.Strings(targets)
+
+		for _, origin := range targets {
+			visited[origin] = true
+			steps := append(append([]Step{}, cur.steps...), next[origin])
+			ambiguous := append(append([]string{}, cur.ambiguities...), ambiguities[origin]...)
+			if origin == to {
+				x.Result, x.Found = next[origin].Result, true
+				x.Steps, x.Ambiguities = steps, ambiguous
+				return x
+			}
+			queue = append(queue, state{origin, next[origin].Result, steps, ambiguous})
+		}
+	}
+
+	return x
+}
+
+// String renders the explanation as human-readable text
+func (x *Explanation) String() string {
+	b := &strings.Builder{}
+	if x.Found {
+		fmt.Fprintf(b, "%v %v -> %v %v\n", x.From, x.Episode, x.To, x.Result)
+	} else {
+		fmt.Fprintf(b, "%v %v -> %v: no mapping found\n", x.From, x.Episode, x.To)
+	}
+	for i, s := range x.Steps {
+		source := s.Source
+		if source == "" {
+			source = "unknown source"
+		}
+		fmt.Fprintf(b, "  %d. %v %v -> %v %v (%v)\n", i+1, s.From, s.Episode, s.To, s.Result, source)
+	}
+	for _, a := range x.Ambiguities {
+		fmt.Fprintf(b, "  ambiguous: %v\n", a)
+	}
+	fmt.Fprintf(b, "  confidence: %.2f (%v)\n", x.Confidence.Score, strings.Join(x.Confidence.Reasons, ", "))
+	return b.String()
+}
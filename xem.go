@@ -2,10 +2,13 @@ package xem
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 )
 
 // Available origin types
@@ -17,15 +20,20 @@ const (
 
 // XEM API URL strings and response constants
 const (
-	defaultUserAgent     = "go-xem-client/0.1"
-	defaultBaseURL       = "http://thexem.de/"
-	defaultAllEndpoint   = "map/all"
-	defaultNamesEndpoint = "map/allNames"
+	defaultUserAgent      = "go-xem-client/0.1"
+	defaultBaseURL        = "http://thexem.de/"
+	defaultAllEndpoint    = "map/all"
+	defaultNamesEndpoint  = "map/allNames"
+	defaultSingleEndpoint = "map/single"
 
 	// Response success indicator
 	success = "success"
 )
 
+// ErrNotFound is returned when XEM has no mapping for a request, as opposed to
+// the request failing.
+var ErrNotFound = errors.New("no mapping found")
+
 // Mapping from origins to Episodes
 type Mapping map[string]Episode
 
@@ -36,14 +44,21 @@ type Episode struct {
 	Absolute int `json:"absolute"`
 }
 
+// Source of mappings between origins, such as the XEM API
+type Source interface {
+	All(origin, id string) ([]Mapping, error)
+}
+
 // Client for the XEM API
 type Client struct {
-	client *http.Client
+	client  *http.Client
+	origins map[string]Origin
 
-	UserAgent     string
-	BaseURL       *url.URL
-	AllEndpoint   *url.URL
-	NamesEndpoint *url.URL
+	UserAgent      string
+	BaseURL        *url.URL
+	AllEndpoint    *url.URL
+	NamesEndpoint  *url.URL
+	SingleEndpoint *url.URL
 }
 
 // NewClient creates a new XEM API client
@@ -55,12 +70,15 @@ func NewClient(httpClient *http.Client) *Client {
 	baseURL, _ := url.Parse(defaultBaseURL)
 	allEndpoint, _ := url.Parse(defaultAllEndpoint)
 	namesEndpoint, _ := url.Parse(defaultNamesEndpoint)
+	singleEndpoint, _ := url.Parse(defaultSingleEndpoint)
 
 	c := &Client{
-		client:        httpClient,
-		BaseURL:       baseURL,
-		AllEndpoint:   allEndpoint,
-		NamesEndpoint: namesEndpoint,
+		client:         httpClient,
+		origins:        make(map[string]Origin),
+		BaseURL:        baseURL,
+		AllEndpoint:    allEndpoint,
+		NamesEndpoint:  namesEndpoint,
+		SingleEndpoint: singleEndpoint,
 	}
 
 	return c
@@ -83,25 +101,64 @@ func (c *Client) NewRequest(method string, resURL *url.URL) (*http.Request, erro
 }
 
 type allResponse struct {
-	Result  string    `json:"result"`
-	Data    []Mapping `json:"data"`
-	Message string    `json:"message"`
+	Result  string         `json:"result"`
+	Data    []MappingEntry `json:"data"`
+	Message string         `json:"message"`
 }
 
-// All retrieves all mappings from the given origin and ID
+// All retrieves all mappings from the given origin and ID. Episodes of
+// registered custom origins are included.
 func (c *Client) All(origin, id string) ([]Mapping, error) {
+	entries, err := c.AllEntries(origin, id)
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]Mapping, len(entries))
+	for i, e := range entries {
+		all[i] = e.Mapping
+	}
+	return all, nil
+}
+
+// AllEntries retrieves all mappings from the given origin and ID, including
+// multi-part episodes and unrecognized fields.
+func (c *Client) AllEntries(origin, id string) ([]MappingEntry, error) {
+	if o, ok := c.origins[origin]; ok {
+		all, err := o.Source.All(origin, id)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]MappingEntry, len(all))
+		for i, m := range all {
+			entries[i] = MappingEntry{Mapping: m}
+		}
+		return entries, nil
+	}
+
 	vals := make(url.Values)
 	vals.Set("origin", origin)
 	vals.Set("id", id)
-	c.AllEndpoint.RawQuery = vals.Encode()
+	// Copy the endpoint, which is shared by concurrent requests
+	u := *c.AllEndpoint
+	u.RawQuery = vals.Encode()
 
 	all := &allResponse{}
-	_, err := c.get(c.AllEndpoint, all)
+	_, err := c.get(&u, all)
 	if err != nil {
 		return nil, err
 	}
 	if all.Result != success {
-		return nil, fmt.Errorf("request failed: %v", all.Message)
+		return nil, requestError(all.Message)
+	}
+
+	mappings := make([]Mapping, len(all.Data))
+	for i, e := range all.Data {
+		mappings[i] = e.Mapping
+	}
+	err = c.joinOrigins(origin, id, mappings)
+	if err != nil {
+		return nil, err
 	}
 
 	return all.Data, nil
@@ -115,24 +172,84 @@ type namesResponse struct {
 
 // Names retrieves the names of
 func (c *Client) Names(origin, lang string) (map[string]([]map[string]int), error) {
+	if o, ok := c.origins[origin]; ok {
+		if o.Names == nil {
+			return nil, fmt.Errorf("origin %v does not provide names", origin)
+		}
+		return o.Names(lang)
+	}
+
 	vals := make(url.Values)
 	vals.Set("origin", origin)
 	vals.Set("seasonNumbers", "1")
 	vals.Set("language", lang)
-	c.NamesEndpoint.RawQuery = vals.Encode()
+	u := *c.NamesEndpoint
+	u.RawQuery = vals.Encode()
 
 	all := &namesResponse{}
-	_, err := c.get(c.NamesEndpoint, all)
+	_, err := c.get(&u, all)
 	if err != nil {
 		return nil, err
 	}
 	if all.Result != success {
-		return nil, fmt.Errorf("request failed: %v", all.Message)
+		return nil, requestError(all.Message)
 	}
 
 	return all.Data, nil
 }
 
+type singleResponse struct {
+	Result  string          `json:"result"`
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
+}
+
+// Single retrieves the mapping of a single episode from the given origin and
+// ID. If destination is not empty, only that origin is included.
+func (c *Client) Single(origin, id string, ep Episode, destination string) (Mapping, error) {
+	vals := make(url.Values)
+	vals.Set("origin", origin)
+	vals.Set("id", id)
+	if ep.HasSeason() {
+		vals.Set("season", strconv.Itoa(ep.Season))
+		vals.Set("episode", strconv.Itoa(ep.Episode))
+	}
+	if ep.HasAbsolute() {
+		vals.Set("absolute", strconv.Itoa(ep.Absolute))
+	}
+	if destination != "" {
+		vals.Set("destination", destination)
+	}
+	u := *c.SingleEndpoint
+	u.RawQuery = vals.Encode()
+
+	single := &singleResponse{}
+	_, err := c.get(&u, single)
+	if err != nil {
+		return nil, err
+	}
+	if single.Result != success {
+		return nil, requestError(single.Message)
+	}
+
+	m := make(Mapping)
+	err = json.Unmarshal(single.Data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode JSON: %v %s", err, string(single.Data))
+	}
+
+	return m, nil
+}
+
+// requestError returns the error for a failed request. XEM reports missing
+// shows and episodes with messages starting with "no".
+func requestError(message string) error {
+	if strings.HasPrefix(strings.ToLower(message), "no ") {
+		return fmt.Errorf("%w: %v", ErrNotFound, message)
+	}
+	return fmt.Errorf("request failed: %v", message)
+}
+
 func (c *Client) get(endpoint *url.URL, result interface{}) (*http.Response, error) {
 	req, err := c.NewRequest("GET", endpoint)
 	if err != nil {